@@ -0,0 +1,297 @@
+package hetzner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/ssh"
+	"github.com/docker/machine/state"
+)
+
+const hcloudBaseURL = "https://api.hetzner.cloud/v1"
+
+type hcloudServer struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	PublicNet struct {
+		IPv4 struct {
+			IP string `json:"ip"`
+		} `json:"ipv4"`
+	} `json:"public_net"`
+}
+
+type hcloudAction struct {
+	ID    int `json:"id"`
+	Status string `json:"status"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type hcloudCreateServerResponse struct {
+	Server hcloudServer `json:"server"`
+	Action hcloudAction `json:"action"`
+}
+
+type hcloudServerResponse struct {
+	Server hcloudServer `json:"server"`
+}
+
+type hcloudActionResponse struct {
+	Action hcloudAction `json:"action"`
+}
+
+type hcloudSSHKey struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+type hcloudCreateSSHKeyResponse struct {
+	SSHKey hcloudSSHKey `json:"ssh_key"`
+}
+
+// hcloudApiCall issues an authenticated request against the Hetzner Cloud API.
+func (d *Driver) hcloudApiCall(method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, hcloudBaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+d.APIToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		contents, _ := ioutil.ReadAll(resp.Body)
+		return resp, fmt.Errorf("hetzner cloud API error (%d): %s", resp.StatusCode, contents)
+	}
+	return resp, nil
+}
+
+// hcloudCreateKeyPair generates a local SSH key pair and registers the
+// public key with Hetzner Cloud, mirroring what createKeyPair does for the
+// Robot driver, and returns the resulting Hetzner Cloud SSH key ID.
+func (d *Driver) hcloudCreateKeyPair() (int, error) {
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return 0, err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.GetSSHKeyPath() + ".pub")
+	if err != nil {
+		return 0, err
+	}
+
+	log.Debugf("creating key pair: %s", d.MachineName)
+
+	resp, err := d.hcloudApiCall("POST", "/ssh_keys", map[string]interface{}{
+		"name":       d.MachineName,
+		"public_key": string(publicKey),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	var created hcloudCreateSSHKeyResponse
+	if err := json.Unmarshal(contents, &created); err != nil {
+		return 0, err
+	}
+
+	return created.SSHKey.ID, nil
+}
+
+// hcloudCreate creates a Cloud server and waits for the create action to
+// finish before returning.
+func (d *Driver) hcloudCreate() error {
+	keyID, err := d.hcloudCreateKeyPair()
+	if err != nil {
+		return fmt.Errorf("unable to create key pair: %s", err)
+	}
+	d.SSHKeyID = strconv.Itoa(keyID)
+
+	sshKeys := []interface{}{keyID}
+	if d.SSHKeys != "" {
+		for _, k := range strings.Split(d.SSHKeys, ",") {
+			sshKeys = append(sshKeys, strings.TrimSpace(k))
+		}
+	}
+
+	payload := map[string]interface{}{
+		"name":        d.MachineName,
+		"server_type": d.ServerType,
+		"image":       d.Image,
+		"ssh_keys":    sshKeys,
+	}
+	if d.Location != "" {
+		payload["location"] = d.Location
+	}
+	if d.Datacenter != "" {
+		payload["datacenter"] = d.Datacenter
+	}
+	if d.UserData != "" {
+		contents, err := ioutil.ReadFile(d.UserData)
+		if err != nil {
+			return fmt.Errorf("unable to read --hetzner-user-data: %s", err)
+		}
+		payload["user_data"] = string(contents)
+	}
+
+	resp, err := d.hcloudApiCall("POST", "/servers", payload)
+	if err != nil {
+		return fmt.Errorf("unable to create server: %s", err)
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var created hcloudCreateServerResponse
+	if err := json.Unmarshal(contents, &created); err != nil {
+		return err
+	}
+
+	d.ServerID = strconv.Itoa(created.Server.ID)
+	d.IPAddress = created.Server.PublicNet.IPv4.IP
+
+	log.Infof("waiting for server %s to be created", d.MachineName)
+	return d.hcloudWaitForAction(created.Action.ID)
+}
+
+// hcloudWaitForAction polls an action until it leaves the "running" state.
+func (d *Driver) hcloudWaitForAction(actionID int) error {
+	for {
+		resp, err := d.hcloudApiCall("GET", fmt.Sprintf("/actions/%d", actionID), nil)
+		if err != nil {
+			return err
+		}
+		contents, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		var parsed hcloudActionResponse
+		if err := json.Unmarshal(contents, &parsed); err != nil {
+			return err
+		}
+
+		switch parsed.Action.Status {
+		case "success":
+			return nil
+		case "error":
+			if parsed.Action.Error != nil {
+				return fmt.Errorf("%s: %s", parsed.Action.Error.Code, parsed.Action.Error.Message)
+			}
+			return fmt.Errorf("action %d failed", actionID)
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// hcloudAction triggers a server action (poweron, poweroff, reset, ...) and
+// waits for it to complete.
+func (d *Driver) hcloudAction(action string) error {
+	path := fmt.Sprintf("/servers/%s/actions/%s", d.ServerID, action)
+	resp, err := d.hcloudApiCall("POST", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var parsed hcloudActionResponse
+	if err := json.Unmarshal(contents, &parsed); err != nil {
+		return err
+	}
+
+	return d.hcloudWaitForAction(parsed.Action.ID)
+}
+
+func (d *Driver) hcloudGetState() (state.State, error) {
+	resp, err := d.hcloudApiCall("GET", fmt.Sprintf("/servers/%s", d.ServerID), nil)
+	if err != nil {
+		return state.Error, err
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return state.Error, err
+	}
+
+	var parsed hcloudServerResponse
+	if err := json.Unmarshal(contents, &parsed); err != nil {
+		return state.Error, err
+	}
+
+	switch parsed.Server.Status {
+	case "running":
+		return state.Running, nil
+	case "off":
+		return state.Stopped, nil
+	case "starting":
+		return state.Starting, nil
+	case "stopping":
+		return state.Stopping, nil
+	default:
+		return state.None, nil
+	}
+}
+
+// hcloudRemove deletes the server and the SSH key that was registered in
+// hcloudCreateKeyPair, mirroring what Remove already does for the Robot
+// driver via d.KeyFingerprint.
+func (d *Driver) hcloudRemove() error {
+	resp, err := d.hcloudApiCall("DELETE", fmt.Sprintf("/servers/%s", d.ServerID), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if d.SSHKeyID != "" {
+		keyResp, err := d.hcloudApiCall("DELETE", fmt.Sprintf("/ssh_keys/%s", d.SSHKeyID), nil)
+		if err != nil {
+			return fmt.Errorf("unable to remove SSH key: %s", err)
+		}
+		keyResp.Body.Close()
+	}
+
+	return nil
+}
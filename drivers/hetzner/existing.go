@@ -0,0 +1,51 @@
+package hetzner
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/docker/machine/log"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+// useExisting installs the user-provided private key at d.GetSSHKeyPath()
+// and verifies SSH connectivity, skipping createKeyPair, the OS install and
+// the hardware reset entirely. It hands off to the normal Docker
+// provisioning step once the server answers over SSH.
+func (d *Driver) useExisting() error {
+	if err := copySSHKey(d.ExistingSSHKey, d.GetSSHKeyPath()); err != nil {
+		return fmt.Errorf("unable to install --hetzner-ssh-key: %s", err)
+	}
+
+	log.Infof("verifying SSH connectivity to %s", d.IPAddress)
+	if _, err := d.waitForSSHAndFingerprint(d.installTimeout()); err != nil {
+		return fmt.Errorf("unable to reach %s over SSH: %s", d.IPAddress, err)
+	}
+
+	return nil
+}
+
+// copySSHKey installs an existing private key at dst, deriving a matching
+// public key either from a sibling ".pub" file or from the private key
+// itself when no ".pub" file is present.
+func copySSHKey(src, dst string) error {
+	contents, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(dst, contents, 0600); err != nil {
+		return err
+	}
+
+	if pub, err := ioutil.ReadFile(src + ".pub"); err == nil {
+		return ioutil.WriteFile(dst+".pub", pub, 0644)
+	}
+
+	signer, err := cryptossh.ParsePrivateKey(contents)
+	if err != nil {
+		return fmt.Errorf("unable to derive public key from %s: %s", src, err)
+	}
+
+	return ioutil.WriteFile(dst+".pub", cryptossh.MarshalAuthorizedKey(signer.PublicKey()), 0644)
+}
@@ -3,21 +3,51 @@ package hetzner
 import (
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/codegangsta/cli"
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/log"
 	"github.com/docker/machine/ssh"
 	"github.com/docker/machine/state"
-	"encoding/json"
 )
 
 type Driver struct {
 	*drivers.BaseDriver
-	Login    string
-	Password string
+	Login          string
+	Password       string
+	ResetType      string
+	CancelOnRemove bool
+	KeyFingerprint string
+
+	// Cloud mode (--hetzner-api=cloud)
+	APIMode    string
+	APIToken   string
+	ServerType string
+	Image      string
+	Location   string
+	Datacenter string
+	SSHKeys    string
+	UserData   string
+	ServerID   string
+	SSHKeyID   string
+
+	// Robot custom OS install (robot mode only)
+	Arch       string
+	Lang       string
+	Rescue     bool
+	Autosetup  string
+	RaidLevel  string
+	RaidDrives string
+
+	InstallTimeout time.Duration
+
+	// Use an already-provisioned server instead of installing one
+	// (robot mode only)
+	UseExisting    bool
+	SSHUser        string
+	ExistingSSHKey string
 }
 
 func init() {
@@ -44,6 +74,103 @@ func GetCreateFlags() []cli.Flag {
 			Usage: "Password for Hetzner Robot",
 			Value: "",
 		},
+		cli.StringFlag{
+			Name: "hetzner-reset-type",
+			Usage: "Reset type used for Restart/Kill: hw, sw or man",
+			Value: "hw",
+		},
+		cli.BoolFlag{
+			Name: "hetzner-cancel-on-remove",
+			Usage: "Schedule cancellation of the server when removing the machine",
+		},
+		cli.StringFlag{
+			Name: "hetzner-api",
+			Usage: "Hetzner API to use: \"robot\" for existing dedicated servers, \"cloud\" to create a new Hetzner Cloud server",
+			Value: "robot",
+		},
+		cli.StringFlag{
+			Name: "hetzner-api-token",
+			Usage: "API token for Hetzner Cloud (required when --hetzner-api=cloud)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name: "hetzner-server-type",
+			Usage: "Hetzner Cloud server type, e.g. cx22 (cloud mode only)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name: "hetzner-image",
+			Usage: "Image/distribution to install: a Hetzner Cloud image name in cloud mode, or a rescue system distribution name in robot mode",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name: "hetzner-location",
+			Usage: "Hetzner Cloud location, e.g. nbg1 (cloud mode only)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name: "hetzner-datacenter",
+			Usage: "Hetzner Cloud datacenter, e.g. nbg1-dc3 (cloud mode only)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name: "hetzner-ssh-keys",
+			Usage: "Comma-separated Hetzner Cloud SSH key names or IDs to attach to the server (cloud mode only)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name: "hetzner-user-data",
+			Usage: "Path to a cloud-init user-data file to pass to the server (cloud mode only)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name: "hetzner-arch",
+			Usage: "Architecture to install in robot mode, e.g. 64 (default: 64)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name: "hetzner-lang",
+			Usage: "Language to install in robot mode, e.g. en (default: en)",
+			Value: "",
+		},
+		cli.BoolFlag{
+			Name: "hetzner-rescue",
+			Usage: "Boot into the Hetzner rescue system and run installimage with --hetzner-autosetup instead of the plain Linux installer (robot mode only)",
+		},
+		cli.StringFlag{
+			Name: "hetzner-autosetup",
+			Usage: "Path to a local installimage autosetup file, uploaded to the rescue system as /autosetup (requires --hetzner-rescue)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name: "hetzner-raid-level",
+			Usage: "Software RAID level to pass to installimage -r, e.g. 1 (requires --hetzner-rescue)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name: "hetzner-raid-drives",
+			Usage: "Comma-separated drives to use for the RAID configured via --hetzner-raid-level",
+			Value: "",
+		},
+		cli.IntFlag{
+			Name: "hetzner-install-timeout",
+			Usage: "Minutes to wait for the install to finish and the target OS to become reachable over SSH",
+			Value: 20,
+		},
+		cli.BoolFlag{
+			Name: "hetzner-use-existing",
+			Usage: "Skip install and reset, and use an already-provisioned server reachable over SSH (robot mode only)",
+		},
+		cli.StringFlag{
+			Name: "hetzner-ssh-user",
+			Usage: "SSH user to connect as (used with --hetzner-use-existing)",
+			Value: "root",
+		},
+		cli.StringFlag{
+			Name: "hetzner-ssh-key",
+			Usage: "Path to an existing private key to use, instead of generating one (requires --hetzner-use-existing)",
+			Value: "",
+		},
 	}
 }
 
@@ -53,31 +180,59 @@ func NewDriver(machineName string, storePath string, caCert string, privateKey s
 }
 
 func (d *Driver) Create() error {
+	if d.APIMode == "cloud" {
+		return d.hcloudCreate()
+	}
+
+	if d.UseExisting {
+		return d.useExisting()
+	}
+
 	fingerprint, err := d.createKeyPair()
 	if err != nil {
 		return fmt.Errorf("unable to create key pair: %s", err)
 	}
+	d.KeyFingerprint = fingerprint
+
+	if d.Rescue {
+		return d.runAutosetupInstall()
+	}
+
+	dist := d.Image
+	if dist == "" {
+		dist = "Ubuntu 14.04.2 LTS minimal"
+	}
+	arch := d.Arch
+	if arch == "" {
+		arch = "64"
+	}
+	lang := d.Lang
+	if lang == "" {
+		lang = "en"
+	}
+
+	client := d.robot()
 
 	// http://wiki.hetzner.de/index.php/Robot_Webservice/en#POST_.2Fboot.2F.3Cserver-ip.3E.2Flinux
-	linux := fmt.Sprintf("/boot/%s/linux", d.IPAddress)
-	resp, err := d.robotApiCall(linux, url.Values{
-		"dist": {"Ubuntu 14.04.2 LTS minimal"},
-		"arch": {"64"},
-		"lang": {"en"},
+	if err := client.Boot(d.IPAddress, "linux", url.Values{
+		"dist": {dist},
+		"arch": {arch},
+		"lang": {lang},
 		"authorized_key": {fingerprint},
-	})
+	}); err != nil {
+		return fmt.Errorf("unable to activate Linux installer: %s", err)
+	}
 
-	if err != nil {
-		return err
+	if err := client.Reset(d.IPAddress, "hw"); err != nil {
+		return fmt.Errorf("unable to reset server: %s", err)
 	}
-	defer resp.Body.Close()
 
-	// http://wiki.hetzner.de/index.php/Robot_Webservice/en#POST_.2Freset.2F.3Cserver-ip.3E
-	reset := fmt.Sprintf("/reset/%s", d.IPAddress)
-	resp, err = d.robotApiCall(reset,	url.Values{"type": {"hw"}})
-	defer resp.Body.Close()
+	log.Infof("installing, waiting for target OS to become reachable over SSH")
+	if _, err := d.waitForSSHAndFingerprint(d.installTimeout()); err != nil {
+		return fmt.Errorf("install did not finish in time: %s", err)
+	}
 
-	return err
+	return nil
 }
 
 func (d *Driver) GetIP() (string, error) {
@@ -91,6 +246,13 @@ func (d *Driver) GetSSHHostname() (string, error) {
 	return d.GetIP()
 }
 
+func (d *Driver) GetSSHUsername() string {
+	if d.SSHUser == "" {
+		return "root"
+	}
+	return d.SSHUser
+}
+
 func (d *Driver) GetURL() (string, error) {
 	ip, err := d.GetIP()
 	if err != nil {
@@ -102,35 +264,133 @@ func (d *Driver) GetURL() (string, error) {
 	return fmt.Sprintf("tcp://%s:2376", ip), nil
 }
 
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#GET_.2Freset.2F.3Cserver-ip.3E
 func (d *Driver) GetState() (state.State, error) {
-	return state.Running, nil
+	if d.APIMode == "cloud" {
+		return d.hcloudGetState()
+	}
+
+	reset, err := d.robot().GetReset(d.IPAddress)
+	if err != nil {
+		return state.Error, err
+	}
+
+	switch reset.OperatingStatus {
+	case "running":
+		return state.Running, nil
+	case "shut off":
+		return state.Stopped, nil
+	default:
+		return state.None, nil
+	}
 }
 
 func (d *Driver) Kill() error {
-	return fmt.Errorf("not yet implemented")
+	if d.APIMode == "cloud" {
+		return d.hcloudAction("poweroff")
+	}
+	return d.hardwareReset()
 }
 
 func (d *Driver) PreCreateCheck() error {
 	return nil
 }
 
+// Remove deletes the SSH key that was registered in createKeyPair and, if
+// --hetzner-cancel-on-remove was set, schedules cancellation of the server.
 func (d *Driver) Remove() error {
-	return fmt.Errorf("not yet implemented")
+	if d.APIMode == "cloud" {
+		return d.hcloudRemove()
+	}
+
+	if d.KeyFingerprint != "" {
+		if err := d.deleteKeyPair(d.KeyFingerprint); err != nil {
+			return fmt.Errorf("unable to remove SSH key: %s", err)
+		}
+	}
+
+	if d.CancelOnRemove {
+		if err := d.cancelServer(); err != nil {
+			return fmt.Errorf("unable to cancel server: %s", err)
+		}
+	}
+
+	return nil
 }
 
 func (d *Driver) Restart() error {
-	return fmt.Errorf("not yet implemented")
+	if d.APIMode == "cloud" {
+		return d.hcloudAction("reset")
+	}
+	return d.hardwareReset()
 }
 
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.IPAddress = flags.String("hetzner-ip-address")
 	d.Login = flags.String("hetzner-login")
 	d.Password = flags.String("hetzner-password")
+	d.ResetType = flags.String("hetzner-reset-type")
+	d.CancelOnRemove = flags.Bool("hetzner-cancel-on-remove")
+	d.APIMode = flags.String("hetzner-api")
+	d.APIToken = flags.String("hetzner-api-token")
+	d.ServerType = flags.String("hetzner-server-type")
+	d.Image = flags.String("hetzner-image")
+	d.Location = flags.String("hetzner-location")
+	d.Datacenter = flags.String("hetzner-datacenter")
+	d.SSHKeys = flags.String("hetzner-ssh-keys")
+	d.UserData = flags.String("hetzner-user-data")
+	d.Arch = flags.String("hetzner-arch")
+	d.Lang = flags.String("hetzner-lang")
+	d.Rescue = flags.Bool("hetzner-rescue")
+	d.Autosetup = flags.String("hetzner-autosetup")
+	d.RaidLevel = flags.String("hetzner-raid-level")
+	d.RaidDrives = flags.String("hetzner-raid-drives")
+	d.InstallTimeout = time.Duration(flags.Int("hetzner-install-timeout")) * time.Minute
+	d.UseExisting = flags.Bool("hetzner-use-existing")
+	d.SSHUser = flags.String("hetzner-ssh-user")
+	d.ExistingSSHKey = flags.String("hetzner-ssh-key")
+
+	if d.ResetType == "" {
+		d.ResetType = "hw"
+	}
+
+	if d.APIMode == "" {
+		d.APIMode = "robot"
+	}
+
+	if d.APIMode != "robot" && d.APIMode != "cloud" {
+		return fmt.Errorf("--hetzner-api must be \"robot\" or \"cloud\", got %q", d.APIMode)
+	}
+
+	if d.APIMode == "cloud" {
+		if d.APIToken == "" {
+			return fmt.Errorf("hetzner driver requires the --hetzner-api-token option in cloud mode")
+		}
+
+		if d.ServerType == "" {
+			return fmt.Errorf("hetzner driver requires the --hetzner-server-type option in cloud mode")
+		}
+
+		if d.Image == "" {
+			return fmt.Errorf("hetzner driver requires the --hetzner-image option in cloud mode")
+		}
+
+		return nil
+	}
 
 	if d.IPAddress == "" {
 		return fmt.Errorf("hetzner driver requires the --hetzner-ip-address option")
 	}
 
+	if d.UseExisting {
+		if d.ExistingSSHKey == "" {
+			return fmt.Errorf("hetzner driver requires the --hetzner-ssh-key option with --hetzner-use-existing")
+		}
+		// Robot credentials are optional here: they are only needed if the
+		// user later calls Restart/Kill/Remove against the Robot API.
+		return nil
+	}
+
 	if d.Login == "" {
 		return fmt.Errorf("hetzner driver requires the --hetzner-login option")
 	}
@@ -139,18 +399,40 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 		return fmt.Errorf("hetzner driver requires the --hetzner-password option")
 	}
 
+	if d.Rescue && d.Autosetup == "" {
+		return fmt.Errorf("hetzner driver requires the --hetzner-autosetup option with --hetzner-rescue")
+	}
+
 	return nil
 }
 
+// Start powers the server on. In robot mode this is not generally
+// supported for Hetzner dedicated servers; it only works for the subset of
+// servers with the remote power switch add-on.
 func (d *Driver) Start() error {
-	return fmt.Errorf("not yet implemented")
+	if d.APIMode == "cloud" {
+		return d.hcloudAction("poweron")
+	}
+	return d.powerReset()
 }
 
+// Stop powers the server off. In robot mode this is not generally
+// supported for Hetzner dedicated servers; it only works for the subset of
+// servers with the remote power switch add-on.
 func (d *Driver) Stop() error {
-	return fmt.Errorf("not yet implemented")
+	if d.APIMode == "cloud" {
+		return d.hcloudAction("poweroff")
+	}
+	return d.powerReset()
 }
 
 
+// robot returns a client for the Robot webservice using the configured
+// credentials.
+func (d *Driver) robot() *robotClient {
+	return newRobotClient(d.Login, d.Password)
+}
+
 func (d *Driver) createKeyPair() (string, error) {
 
 	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
@@ -166,7 +448,54 @@ func (d *Driver) createKeyPair() (string, error) {
 
 	log.Debugf("creating key pair: %s", keyName)
 
-	return d.importKeyPair(keyName, string(publicKey))
+	key, err := d.robot().AddKey(keyName, string(publicKey))
+	if err != nil {
+		return "", err
+	}
+	return key.Fingerprint, nil
+}
+
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#POST_.2Freset.2F.3Cserver-ip.3E
+func (d *Driver) hardwareReset() error {
+	if err := d.robot().Reset(d.IPAddress, d.ResetType); err != nil {
+		return fmt.Errorf("unable to reset server: %s", err)
+	}
+	return nil
+}
+
+// powerReset presses the remote power switch via the reset API. Not every
+// dedicated server has a power switch attached, in which case Hetzner
+// reports RESET_NOT_AVAILABLE rather than performing the reset; any other
+// error (rate limiting, auth failures, network errors) is surfaced as-is
+// rather than misreported as a missing power switch.
+func (d *Driver) powerReset() error {
+	if err := d.robot().Reset(d.IPAddress, "power"); err != nil {
+		if robotErr, ok := err.(*RobotError); ok && robotErr.Code == "RESET_NOT_AVAILABLE" {
+			return fmt.Errorf("start/stop is not supported on this server: %s", err)
+		}
+		return fmt.Errorf("unable to reset server: %s", err)
+	}
+	return nil
+}
+
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#DELETE_.2Fkey.2F.3Cfingerprint.3E
+func (d *Driver) deleteKeyPair(fingerprint string) error {
+	return d.robot().DeleteKey(fingerprint)
+}
+
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#POST_.2Fserver.2F.3Cserver-ip.3E.2Fcancellation
+func (d *Driver) cancelServer() error {
+	return d.robot().Cancel(d.IPAddress, "now")
+}
+
+type ResetResponse struct {
+	Reset Reset `json:"reset"`
+}
+
+type Reset struct {
+	ServerIP        string   `json:"server_ip"`
+	Type            []string `json:"type"`
+	OperatingStatus string   `json:"operating_status"`
 }
 
 type KeyRespone struct {
@@ -179,42 +508,4 @@ type Key struct {
 	Type        string `json:"type"`
 	Size        int    `json:"size"`
 	Data        string `json:"data"`
-}
-
-// http://wiki.hetzner.de/index.php/Robot_Webservice/en#POST_.2Fkey
-func (d *Driver) importKeyPair(name, publicKey string) (string, error) {
-	resp, err := d.robotApiCall("/key", url.Values{
-		"name": {name},
-		"data": {publicKey},
-	})
-
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	contents, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-	unmarshalledResponse := KeyRespone{}
-	err = json.Unmarshal(contents, &unmarshalledResponse)
-	return unmarshalledResponse.Key.Fingerprint, err
-}
-
-// http://wiki.hetzner.de/index.php/Robot_Webservice/en
-func (d *Driver) robotApiCall(path string, v url.Values) (*http.Response, error) {
-	url := fmt.Sprintf("https://%s:%s@robot-ws.your-server.de%s",
-		d.Login,
-		d.Password,
-		path,
-	)
-	resp, err := http.PostForm(url, v)
-	if err != nil {
-		return resp, err
-	}
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return resp, fmt.Errorf("%s", resp)
-	}
-	return resp, nil
 }
\ No newline at end of file
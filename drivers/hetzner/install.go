@@ -0,0 +1,87 @@
+package hetzner
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/docker/machine/log"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+const defaultInstallTimeout = 20 * time.Minute
+
+// waitForSSHAndFingerprint blocks until TCP/22 on d.IPAddress is reachable
+// and an SSH handshake succeeds, then returns the host key fingerprint of
+// whatever answered.
+func (d *Driver) waitForSSHAndFingerprint(timeout time.Duration) (string, error) {
+	if err := waitForSSHPort(d.IPAddress, timeout); err != nil {
+		return "", err
+	}
+
+	config, err := d.rescueSSHConfig()
+	if err != nil {
+		return "", err
+	}
+
+	var fingerprint string
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key cryptossh.PublicKey) error {
+		fingerprint = cryptossh.FingerprintSHA256(key)
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		client, err := cryptossh.Dial("tcp", net.JoinHostPort(d.IPAddress, "22"), config)
+		if err == nil {
+			client.Close()
+			return fingerprint, nil
+		}
+		lastErr = err
+		time.Sleep(5 * time.Second)
+	}
+
+	return "", fmt.Errorf("timed out waiting for an SSH handshake with %s: %s", d.IPAddress, lastErr)
+}
+
+// waitForHostKeyChange waits until the server's SSH host key fingerprint
+// differs from priorFingerprint, which indicates the machine has rebooted
+// into a newly installed OS rather than still answering as the rescue
+// system.
+func (d *Driver) waitForHostKeyChange(priorFingerprint string, timeout time.Duration) error {
+	config, err := d.rescueSSHConfig()
+	if err != nil {
+		return err
+	}
+
+	var fingerprint string
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key cryptossh.PublicKey) error {
+		fingerprint = cryptossh.FingerprintSHA256(key)
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		client, err := cryptossh.Dial("tcp", net.JoinHostPort(d.IPAddress, "22"), config)
+		if err == nil {
+			client.Close()
+			if priorFingerprint == "" || fingerprint != priorFingerprint {
+				return nil
+			}
+		} else {
+			lastErr = err
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	return fmt.Errorf("timed out waiting for %s to come back up as the installed OS: %s", d.IPAddress, lastErr)
+}
+
+func (d *Driver) installTimeout() time.Duration {
+	if d.InstallTimeout == 0 {
+		return defaultInstallTimeout
+	}
+	return d.InstallTimeout
+}
@@ -0,0 +1,169 @@
+package hetzner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/log"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+// runAutosetupInstall boots the server into the Hetzner rescue system,
+// uploads the autosetup file and runs installimage against it, then
+// reboots into the freshly installed system.
+func (d *Driver) runAutosetupInstall() error {
+	osName := d.Image
+	if osName == "" {
+		osName = "Linux"
+	}
+	arch := d.Arch
+	if arch == "" {
+		arch = "64"
+	}
+
+	// http://wiki.hetzner.de/index.php/Robot_Webservice/en#POST_.2Fboot.2F.3Cserver-ip.3E.2Frescue
+	if err := d.robot().Boot(d.IPAddress, "rescue", url.Values{
+		"os":             {osName},
+		"arch":           {arch},
+		"authorized_key": {d.KeyFingerprint},
+	}); err != nil {
+		return fmt.Errorf("unable to activate rescue system: %s", err)
+	}
+
+	if err := d.hardwareReset(); err != nil {
+		return fmt.Errorf("unable to reset into rescue system: %s", err)
+	}
+
+	log.Infof("waiting for rescue system to become reachable over SSH")
+	rescueFingerprint, err := d.waitForSSHAndFingerprint(d.installTimeout())
+	if err != nil {
+		return fmt.Errorf("rescue system did not become reachable: %s", err)
+	}
+
+	config, err := d.rescueSSHConfig()
+	if err != nil {
+		return err
+	}
+
+	client, err := cryptossh.Dial("tcp", net.JoinHostPort(d.IPAddress, "22"), config)
+	if err != nil {
+		return fmt.Errorf("unable to connect to rescue system: %s", err)
+	}
+	defer client.Close()
+
+	contents, err := ioutil.ReadFile(d.Autosetup)
+	if err != nil {
+		return fmt.Errorf("unable to read --hetzner-autosetup: %s", err)
+	}
+	if d.RaidLevel != "" {
+		contents = applyRaidDirectives(contents, d.RaidLevel, d.RaidDrives)
+	}
+	if err := copyFileOverSSH(client, "/autosetup", contents); err != nil {
+		return fmt.Errorf("unable to upload autosetup file: %s", err)
+	}
+
+	const installCmd = "installimage -a -c /autosetup"
+	log.Infof("installing, running %s", installCmd)
+	if err := runSSHCommand(client, installCmd); err != nil {
+		return fmt.Errorf("installimage failed: %s", err)
+	}
+
+	log.Infof("rebooting into the installed system")
+	if err := runSSHCommand(client, "reboot"); err != nil {
+		log.Debugf("reboot command returned an error (expected once the connection drops): %s", err)
+	}
+
+	log.Infof("waiting for target OS to become reachable over SSH")
+	if err := d.waitForHostKeyChange(rescueFingerprint, d.installTimeout()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// applyRaidDirectives prepends the SWRAID/SWRAIDLEVEL/DRIVEn directives
+// derived from --hetzner-raid-level and --hetzner-raid-drives to an
+// autosetup file. installimage has no RAID command-line flags; RAID is
+// configured entirely through these directives in the autosetup file, so
+// the directives we generate here take effect the same way hand-written
+// ones would.
+func applyRaidDirectives(contents []byte, raidLevel, raidDrives string) []byte {
+	directives := []string{
+		"SWRAID 1",
+		"SWRAIDLEVEL " + raidLevel,
+	}
+	for i, drive := range strings.Split(raidDrives, ",") {
+		directives = append(directives, fmt.Sprintf("DRIVE%d %s", i+1, strings.TrimSpace(drive)))
+	}
+	directives = append(directives, "")
+
+	return append([]byte(strings.Join(directives, "\n")), contents...)
+}
+
+func (d *Driver) rescueSSHConfig() (*cryptossh.ClientConfig, error) {
+	key, err := ioutil.ReadFile(d.GetSSHKeyPath())
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := cryptossh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cryptossh.ClientConfig{
+		User:            d.GetSSHUsername(),
+		Auth:            []cryptossh.AuthMethod{cryptossh.PublicKeys(signer)},
+		HostKeyCallback: cryptossh.InsecureIgnoreHostKey(),
+	}, nil
+}
+
+func waitForSSHPort(addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(addr, "22"), 5*time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(5 * time.Second)
+	}
+	return fmt.Errorf("timed out waiting for SSH on %s", addr)
+}
+
+func runSSHCommand(client *cryptossh.Client, cmd string) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	return session.Run(cmd)
+}
+
+func copyFileOverSSH(client *cryptossh.Client, remotePath string, contents []byte) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := session.Start(fmt.Sprintf("cat > %s", remotePath)); err != nil {
+		return err
+	}
+
+	if _, err := stdin.Write(contents); err != nil {
+		return err
+	}
+	stdin.Close()
+
+	return session.Wait()
+}
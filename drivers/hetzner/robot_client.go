@@ -0,0 +1,217 @@
+package hetzner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	robotBaseURL    = "https://robot-ws.your-server.de"
+	robotMaxRetries = 5
+)
+
+// robotBaseURLOverride lets tests point the client at an httptest.Server
+// instead of the real Robot webservice.
+var robotBaseURLOverride string
+
+func robotURL(path string) string {
+	base := robotBaseURL
+	if robotBaseURLOverride != "" {
+		base = robotBaseURLOverride
+	}
+	return base + path
+}
+
+// RobotError is Hetzner Robot's documented error envelope:
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#Error_codes
+type RobotError struct {
+	HTTPStatus int    `json:"status"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *RobotError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+type robotErrorEnvelope struct {
+	Error *RobotError `json:"error"`
+}
+
+// robotClient talks to the Hetzner Robot webservice with HTTP basic auth,
+// typed error decoding, and retries for idempotent/rate-limited requests.
+type robotClient struct {
+	login    string
+	password string
+	client   *http.Client
+}
+
+func newRobotClient(login, password string) *robotClient {
+	return &robotClient{login: login, password: password, client: http.DefaultClient}
+}
+
+// do issues a form-encoded request against the Robot API. GET requests and
+// requests that fail with a rate-limit error are retried with exponential
+// backoff, honoring Retry-After when Hetzner provides it.
+func (c *robotClient) do(method, path string, form url.Values) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		reqURL := robotURL(path)
+		var body *strings.Reader
+		if method == "GET" || method == "DELETE" {
+			if len(form) > 0 {
+				reqURL += "?" + form.Encode()
+			}
+			body = strings.NewReader("")
+		} else {
+			body = strings.NewReader(form.Encode())
+		}
+
+		req, err := http.NewRequest(method, reqURL, body)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(c.login, c.password)
+		if method != "GET" && method != "DELETE" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+			return resp, nil
+		}
+
+		contents, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		robotErr := decodeRobotError(resp.StatusCode, contents)
+
+		retryable := method == "GET" || robotErr.Code == "RATE_LIMIT_EXCEEDED"
+		if retryable && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500) && attempt < robotMaxRetries {
+			time.Sleep(retryDelay(resp, attempt))
+			continue
+		}
+
+		return nil, robotErr
+	}
+}
+
+func decodeRobotError(status int, contents []byte) *RobotError {
+	var envelope robotErrorEnvelope
+	if err := json.Unmarshal(contents, &envelope); err == nil && envelope.Error != nil {
+		return envelope.Error
+	}
+	return &RobotError{HTTPStatus: status, Code: "UNKNOWN", Message: string(contents)}
+}
+
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+	if ra := resp.Header.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.Atoi(ra); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return time.Duration(math.Pow(2, float64(attempt))) * time.Second
+}
+
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#GET_.2Fserver.2F.3Cserver-ip.3E
+func (c *robotClient) GetServer(ip string) (*RobotServer, error) {
+	resp, err := c.do("GET", fmt.Sprintf("/server/%s", ip), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed struct {
+		Server RobotServer `json:"server"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+	return &parsed.Server, nil
+}
+
+type RobotServer struct {
+	ServerIP   string `json:"server_ip"`
+	ServerName string `json:"server_name"`
+	Status     string `json:"status"`
+}
+
+// Boot activates a boot configuration, e.g. mode "linux" or "rescue".
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#POST_.2Fboot.2F.3Cserver-ip.3E.2Flinux
+func (c *robotClient) Boot(ip, mode string, params url.Values) error {
+	resp, err := c.do("POST", fmt.Sprintf("/boot/%s/%s", ip, mode), params)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#POST_.2Freset.2F.3Cserver-ip.3E
+func (c *robotClient) Reset(ip, resetType string) error {
+	resp, err := c.do("POST", fmt.Sprintf("/reset/%s", ip), url.Values{"type": {resetType}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#GET_.2Freset.2F.3Cserver-ip.3E
+func (c *robotClient) GetReset(ip string) (*Reset, error) {
+	resp, err := c.do("GET", fmt.Sprintf("/reset/%s", ip), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed ResetResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+	return &parsed.Reset, nil
+}
+
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#POST_.2Fkey
+func (c *robotClient) AddKey(name, publicKey string) (*Key, error) {
+	resp, err := c.do("POST", "/key", url.Values{"name": {name}, "data": {publicKey}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed KeyRespone
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+	return &parsed.Key, nil
+}
+
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#DELETE_.2Fkey.2F.3Cfingerprint.3E
+func (c *robotClient) DeleteKey(fingerprint string) error {
+	resp, err := c.do("DELETE", fmt.Sprintf("/key/%s", fingerprint), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// http://wiki.hetzner.de/index.php/Robot_Webservice/en#POST_.2Fserver.2F.3Cserver-ip.3E.2Fcancellation
+func (c *robotClient) Cancel(ip, cancellationDate string) error {
+	resp, err := c.do("POST", fmt.Sprintf("/server/%s/cancellation", ip), url.Values{"cancellation_date": {cancellationDate}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,97 @@
+package hetzner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRobotClientGetServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login, password, ok := r.BasicAuth()
+		if !ok || login != "user" || password != "pass" {
+			t.Fatalf("expected basic auth user/pass, got %q/%q (ok=%v)", login, password, ok)
+		}
+		if r.URL.Path != "/server/1.2.3.4" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"server":{"server_ip":"1.2.3.4","server_name":"test","status":"ready"}}`))
+	}))
+	defer server.Close()
+
+	client := newRobotClient("user", "pass")
+	client.client = server.Client()
+
+	withRobotBaseURL(server.URL, func() {
+		srv, err := client.GetServer("1.2.3.4")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if srv.Status != "ready" {
+			t.Fatalf("expected status %q, got %q", "ready", srv.Status)
+		}
+	})
+}
+
+func TestRobotClientDecodesErrorEnvelope(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"status":404,"code":"SERVER_NOT_FOUND","message":"server not found"}}`))
+	}))
+	defer server.Close()
+
+	client := newRobotClient("user", "pass")
+	client.client = server.Client()
+
+	withRobotBaseURL(server.URL, func() {
+		_, err := client.GetServer("1.2.3.4")
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		robotErr, ok := err.(*RobotError)
+		if !ok {
+			t.Fatalf("expected a *RobotError, got %T: %s", err, err)
+		}
+		if robotErr.Code != "SERVER_NOT_FOUND" {
+			t.Fatalf("expected code %q, got %q", "SERVER_NOT_FOUND", robotErr.Code)
+		}
+	})
+}
+
+func TestRobotClientRetriesRateLimit(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"status":429,"code":"RATE_LIMIT_EXCEEDED","message":"rate limit exceeded"}}`))
+			return
+		}
+		w.Write([]byte(`{"server":{"server_ip":"1.2.3.4","server_name":"test","status":"ready"}}`))
+	}))
+	defer server.Close()
+
+	client := newRobotClient("user", "pass")
+	client.client = server.Client()
+
+	withRobotBaseURL(server.URL, func() {
+		_, err := client.GetServer("1.2.3.4")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+// withRobotBaseURL temporarily points the package-level Robot base URL at a
+// test server for the duration of fn.
+func withRobotBaseURL(url string, fn func()) {
+	original := robotBaseURLOverride
+	robotBaseURLOverride = url
+	defer func() { robotBaseURLOverride = original }()
+	fn()
+}